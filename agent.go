@@ -2,14 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	openrtb "gopkg.in/bsm/openrtb.v2"
@@ -52,10 +55,17 @@ type Agent struct {
 	Period  int `json:"period"`
 	Balance int `json:"balance"`
 
+	// Strategy picks how this agent bids. See strategy.go. The zero value
+	// (an empty Type) resolves to the historical fixed-price/random-
+	// creative behavior.
+	Strategy StrategyConfig `json:"strategy"`
+
 	// private state of each agent
-	registered bool      // did we register the configuration in the ACS?
-	pacer      chan bool // go routine updating balance in the banker
-	bidId      int       // unique id for response
+	registered             bool      // did we register the configuration in the ACS?
+	pacer                  chan bool // go routine updating balance in the banker
+	bidId                  int       // unique id for response
+	strategy               BidStrategy
+	remainingBalanceMicros int64 // last balance the banker reported back to the pacer, in USD/1M
 }
 
 type creativesKey struct {
@@ -108,6 +118,7 @@ func (agent *Agent) StartPacer(
 	body := fmt.Sprintf("{\"USD/1M\": %d}", agent.Balance)
 	ticker := time.NewTicker(time.Duration(agent.Period) * time.Millisecond)
 	agent.pacer = make(chan bool)
+	atomic.StoreInt64(&agent.remainingBalanceMicros, int64(agent.Balance))
 
 	go func() {
 		for {
@@ -123,7 +134,8 @@ func (agent *Agent) StartPacer(
 						log.Printf("Balance failed with %s\n", err)
 						return
 					}
-					res.Body.Close()
+					defer res.Body.Close()
+					agent.readBankerBalance(res.Body)
 				}()
 			case <-agent.pacer:
 				ticker.Stop()
@@ -133,31 +145,80 @@ func (agent *Agent) StartPacer(
 	}()
 }
 
+// readBankerBalance updates remainingBalanceMicros from the banker's
+// response, so pacing-aware strategies (see PacingAwareStrategy) can read
+// back what the banker actually granted instead of assuming the full
+// requested balance. It's best effort: a banker that doesn't echo a
+// balance just leaves the last known value in place.
+func (agent *Agent) readBankerBalance(body io.Reader) {
+	var parsed struct {
+		Balance *int64 `json:"USD/1M"`
+	}
+	if err := json.NewDecoder(body).Decode(&parsed); err != nil || parsed.Balance == nil {
+		return
+	}
+	atomic.StoreInt64(&agent.remainingBalanceMicros, *parsed.Balance)
+}
+
+// RemainingBalance returns the last balance the banker reported back for
+// this agent, in USD/1M micros. Until the banker's first reply it is the
+// agent's configured Balance, which StartPacer stores.
+func (agent *Agent) RemainingBalance() int64 {
+	return atomic.LoadInt64(&agent.remainingBalanceMicros)
+}
+
 // Stops the go routine updating the bank balance.
 func (agent *Agent) StopPacer() {
 	close(agent.pacer)
 }
 
-// Adds to the bid response the bid by the agent. The Bid is added to
-// the only seat of the response. It picks a random creative from
-// the list of creatives from the `Agent.Config.Creative` and places it
-// in the bid.
+// Adds to the bid response the bids produced by the agent's BidStrategy
+// (see strategy.go), one per eligible impression. Bids are grouped into
+// the SeatBid the strategy reports via SeatID, creating it if needed, so a
+// strategy can bid under a different seat than "default".
+//
+// ctx carries the auction's deadline (see auctionDeadline in auctions.go).
+// DoBid checks it before considering each impression so a slow agent stops
+// producing bids the exchange would just throw away.
 func (agent *Agent) DoBid(
-	req *openrtb.BidRequest, res *openrtb.BidResponse, ids map[creativesKey]interface{}) (*openrtb.BidResponse, bool) {
+	ctx context.Context, req *openrtb.BidRequest, res *openrtb.BidResponse, ids map[creativesKey]interface{}) (*openrtb.BidResponse, bool) {
 
 	for _, imp := range req.Imp {
+		select {
+		case <-ctx.Done():
+			atomic.AddInt64(&deadlineExceededBids, 1)
+			return res, bidResponseHasBids(res)
+		default:
+		}
+
 		key := creativesKey{ImpId: imp.ID, ExtId: agent.Config.ExternalId}
 		if ids[key] == nil {
 			continue
 		}
 		creativeList := ids[key].([]interface{})
-		// pick a random creative
-		n := rand.Intn(len(creativeList))
 
-		// JSON reads numbers as float64...
-		cridx := int(creativeList[n].(float64))
-		// ...but this (`cridx` see below) is an index.
-		creative := agent.Config.Creatives[cridx]
+		eligible := make([]Creative, 0, len(creativeList))
+		for _, c := range creativeList {
+			// JSON reads numbers as float64...
+			// ...but this (`cridx` see below) is an index.
+			f, ok := c.(float64)
+			cridx := int(f)
+			if !ok || cridx < 0 || cridx >= len(agent.Config.Creatives) {
+				continue
+			}
+			eligible = append(eligible, agent.Config.Creatives[cridx])
+		}
+
+		creative, ok := agent.strategy.SelectCreative(imp, eligible)
+		if !ok {
+			continue
+		}
+
+		price := agent.strategy.PriceBid(req, imp, *creative)
+		if price <= 0 {
+			continue
+		}
+
 		crid := strconv.Itoa(creative.Id)
 
 		// the `bidId` should be something else,
@@ -165,15 +226,40 @@ func (agent *Agent) DoBid(
 		// but we are not tracking anything yet.
 		bidId := strconv.Itoa(agent.bidId)
 
-		price := float64(agent.Price)
-
 		ext := map[string]interface{}{"priority": 1.0, "external-id": agent.Config.ExternalId}
 		jsonExt, _ := json.Marshal(ext)
 		bid := openrtb.Bid{ID: bidId, ImpID: imp.ID, CreativeID: crid, Price: price, Ext: jsonExt}
 		agent.bidId += 1
-		res.SeatBid[0].Bid = append(res.SeatBid[0].Bid, bid)
+
+		seat := seatBidIndex(res, agent.strategy.SeatID(*creative))
+		res.SeatBid[seat].Bid = append(res.SeatBid[seat].Bid, bid)
 	}
-	return res, len(res.SeatBid[0].Bid) > 0
+	return res, bidResponseHasBids(res)
+}
+
+// bidResponseHasBids reports whether any seat in the response carries at
+// least one bid, now that DoBid can spread bids across several seats.
+func bidResponseHasBids(res *openrtb.BidResponse) bool {
+	for _, seat := range res.SeatBid {
+		if len(seat.Bid) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// seatBidIndex returns the index into res.SeatBid for seatID, creating and
+// appending an empty SeatBid if none exists yet. Callers must re-resolve
+// the index on every use rather than caching a *SeatBid across appends:
+// appending to res.SeatBid can move the backing array.
+func seatBidIndex(res *openrtb.BidResponse, seatID string) int {
+	for i := range res.SeatBid {
+		if res.SeatBid[i].Seat == seatID {
+			return i
+		}
+	}
+	res.SeatBid = append(res.SeatBid, openrtb.SeatBid{Seat: seatID, Bid: make([]openrtb.Bid, 0)})
+	return len(res.SeatBid) - 1
 }
 
 func externalIdsFromRequest(req *openrtb.BidRequest) map[creativesKey]interface{} {
@@ -181,13 +267,33 @@ func externalIdsFromRequest(req *openrtb.BidRequest) map[creativesKey]interface{
 	// to a slice of "creative indexes" (See the agent configuration "creative").
 	// We use this auxiliary function in `DoBid` to match the `BidRequest` to the
 	// creatives of the agent and create a response.
+	//
+	// Every assertion below is comma-ok: an imp missing "external-ids"/
+	// "creative-ids", or with the wrong shape, just contributes nothing to
+	// the map instead of panicking. DoBid already treats a missing key as
+	// "no creatives for this imp" and skips it, so a malformed imp quietly
+	// no-bids rather than taking down the handler - important now that a
+	// gRPC client, not just the HTTP exchange, can send the request.
 	ids := make(map[creativesKey]interface{})
 
 	for _, imp := range req.Imp {
-		for _, extID := range imp.Ext["external-ids"].([]interface{}) {
-			key := creativesKey{ImpId: imp.ID, ExtId: int(extID.(float64))}
-			creatives := (imp.Ext["creative-ids"].(map[string]interface{}))[strconv.Itoa(int(extID.(float64)))]
-			ids[key] = creatives.(interface{})
+		externalIDs, ok := imp.Ext["external-ids"].([]interface{})
+		if !ok {
+			continue
+		}
+		creativeIDs, ok := imp.Ext["creative-ids"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, rawExtID := range externalIDs {
+			extID, ok := rawExtID.(float64)
+			if !ok {
+				continue
+			}
+			if creatives, ok := creativeIDs[strconv.Itoa(int(extID))].([]interface{}); ok {
+				ids[creativesKey{ImpId: imp.ID, ExtId: int(extID)}] = creatives
+			}
 		}
 	}
 	return ids
@@ -213,6 +319,10 @@ func LoadAgent(filepath string) (Agent, error) {
 	if err != nil {
 		return Agent{}, err
 	}
+	agent.strategy, err = agent.resolveStrategy()
+	if err != nil {
+		return Agent{}, err
+	}
 	return agent, nil
 }
 
@@ -229,5 +339,12 @@ func LoadAgentsFromFile(filepath string) ([]Agent, error) {
 	if err != nil {
 		return agents, err
 	}
+
+	for i := range agents {
+		agents[i].strategy, err = agents[i].resolveStrategy()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return agents, nil
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	openrtb "gopkg.in/bsm/openrtb.v2"
+)
+
+// defaultTMaxMs is the auction budget used when the exchange doesn't set
+// BidRequest.TMax. Overridable with --default-tmax-ms.
+var defaultTMaxMs = 100
+
+// deadlineSafetyMarginMs is carved off the end of TMax so our response has
+// a chance to reach the exchange before it gives up on us.
+const deadlineSafetyMarginMs = 15
+
+var deadlineExceededBids int64
+
+// DeadlineExceededBids returns the number of times an agent's DoBid stopped
+// early because the auction's deadline had passed before it got to an
+// impression (at most once per agent per auction). Bids that Auction
+// discards when it returns a no-bid after the deadline are not counted.
+// Logged by logAuctionStats.
+func DeadlineExceededBids() int64 {
+	return atomic.LoadInt64(&deadlineExceededBids)
+}
+
+// logAuctionStats logs the auction-level counters (currently just
+// deadline-exceeded bids) once. Called from startOperationalStatOutput's
+// ticker (see main.go).
+func logAuctionStats() {
+	log.Printf("auction stats: deadline-exceeded-bids=%d", DeadlineExceededBids())
+}
+
+// auctionDeadline derives a context carrying the auction's deadline from the
+// request's TMax, falling back to defaultTMaxMs when the exchange didn't set
+// one. context.WithTimeout starts a single timer for the returned context,
+// and its Done() channel is closed (not sent on) when that timer fires, so
+// every agent goroutine handling the auction can select on the same Done()
+// channel without us allocating a timer per agent.
+func auctionDeadline(req *openrtb.BidRequest) (context.Context, context.CancelFunc) {
+	tmaxMs := req.TMax
+	if tmaxMs <= 0 {
+		tmaxMs = int64(defaultTMaxMs)
+	}
+	budget := time.Duration(tmaxMs)*time.Millisecond - deadlineSafetyMarginMs*time.Millisecond
+	if budget < 0 {
+		budget = 0
+	}
+	return context.WithTimeout(context.Background(), budget)
+}
+
+// remainingMs reports how much of the auction's budget is left, in
+// milliseconds, as of now, and 0 once the deadline has passed. It returns
+// -1 if ctx carries no deadline.
+func remainingMs(ctx context.Context) float64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return -1
+	}
+	remaining := float64(time.Until(deadline)) / float64(time.Millisecond)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Auction is the transport-agnostic bidding pipeline: given a BidRequest the
+// caller has already decoded, give every agent a chance to bid under ctx's deadline, and
+// return the combined bid response. Both the fasthttp handler below and the
+// gRPC BiddingService (see grpc.go) call this directly, so the two
+// transports can't drift apart. Agents whose Config.MinTimeAvailableMs
+// exceeds the remaining budget are skipped up front instead of being given
+// a context that's already doomed.
+//
+// If ctx is cancelled (the auction's deadline fires while agents are still
+// bidding), Auction returns a bid-less response rather than an error, so a
+// blown deadline looks the same as a genuine no-bid to the caller.
+func Auction(ctx context.Context, req *openrtb.BidRequest, agents []Agent) (*openrtb.BidResponse, error) {
+	res := emptyResponseWithOneSeat(req)
+	ids := externalIdsFromRequest(req)
+
+	var mu sync.Mutex
+	var pending sync.WaitGroup
+
+	for i := range agents {
+		agent := &agents[i]
+
+		if budget := remainingMs(ctx); budget >= 0 && agent.Config.MinTimeAvailableMs > budget {
+			continue
+		}
+
+		pending.Add(1)
+		go func(agent *Agent) {
+			defer pending.Done()
+
+			agentRes := emptyResponseWithOneSeat(req)
+			_, hasBids := agent.DoBid(ctx, req, agentRes, ids)
+			if !hasBids {
+				return
+			}
+
+			mu.Lock()
+			mergeSeatBids(res, agentRes)
+			mu.Unlock()
+		}(agent)
+	}
+
+	// pending.Wait() alone has no bound: DoBid only checks ctx.Done()
+	// between impressions, so a strategy or downstream call that blocks
+	// inside one impression would otherwise hold the auction open past
+	// ctx's deadline instead of TMax actually capping wall-clock time.
+	// Waiting on done in a select lets Auction give up and return the
+	// moment ctx fires; the straggling goroutines finish on their own and
+	// their (mutex-guarded) writes to res are simply never read.
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	if ctx.Err() != nil {
+		return emptyResponseWithOneSeat(req), nil
+	}
+	pruneEmptySeats(res)
+	return res, nil
+}
+
+// pruneEmptySeats drops zero-bid SeatBid entries, including the default
+// seat emptyResponseWithOneSeat pre-seeds, so a transport that marshals res
+// directly (fastHandleAuctions) doesn't ship a spurious
+// {"seat":"","bid":[]} entry to exchanges that reject it once a strategy
+// has actually bid under a non-default seat (see BidStrategy.SeatID).
+func pruneEmptySeats(res *openrtb.BidResponse) {
+	kept := res.SeatBid[:0]
+	for _, seat := range res.SeatBid {
+		if len(seat.Bid) > 0 {
+			kept = append(kept, seat)
+		}
+	}
+	res.SeatBid = kept
+}
+
+// mergeSeatBids appends every bid in src into the matching (or newly
+// created) SeatBid of dst, keyed by seat ID, so agents bidding under
+// different seats (see BidStrategy.SeatID) don't clobber each other.
+func mergeSeatBids(dst *openrtb.BidResponse, src *openrtb.BidResponse) {
+	for _, seat := range src.SeatBid {
+		if len(seat.Bid) == 0 {
+			continue
+		}
+		idx := seatBidIndex(dst, seat.Seat)
+		dst.SeatBid[idx].Bid = append(dst.SeatBid[idx].Bid, seat.Bid...)
+	}
+}
+
+// fastHandleAuctions is the fasthttp entry point for /auctions: it decodes
+// the OpenRTB bid request, derives the auction's deadline from TMax, and
+// writes back whatever Auction comes up with.
+func fastHandleAuctions(fctx *fasthttp.RequestCtx, agents []Agent) {
+	var req openrtb.BidRequest
+	if err := json.Unmarshal(fctx.PostBody(), &req); err != nil {
+		fctx.Error("bad request", fasthttp.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := auctionDeadline(&req)
+	defer cancel()
+
+	res, err := Auction(ctx, &req, agents)
+	if err != nil {
+		log.Printf("auction failed: %s\n", err)
+		fctx.Error("internal error", fasthttp.StatusInternalServerError)
+		return
+	}
+
+	if !bidResponseHasBids(res) {
+		fctx.SetStatusCode(fasthttp.StatusNoContent)
+		return
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		log.Printf("failed to marshal bid response: %s\n", err)
+		fctx.Error("internal error", fasthttp.StatusInternalServerError)
+		return
+	}
+	fctx.SetContentType("application/json")
+	fctx.SetBody(body)
+}
@@ -0,0 +1,136 @@
+package main
+
+//go:generate protoc --go_out=. --go-grpc_out=. proto/bidding.proto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/connectedinteractive/bidderd/rtbpb"
+	openrtb "gopkg.in/bsm/openrtb.v2"
+)
+
+// grpcPort is the listen port for the gRPC bidding endpoint. 0 disables it,
+// the same convention the win/event/error ports would use if they were
+// optional.
+var grpcPort int
+var grpcTLSCertFile string
+var grpcTLSKeyFile string
+
+// biddingServer implements rtbpb.BiddingServiceServer by translating the
+// typed OpenRTB protobuf messages to/from the openrtb.v2 structs the rest of
+// the bidder works with, and running the auction through the same Auction
+// pipeline fastHandleAuctions uses.
+type biddingServer struct {
+	rtbpb.UnimplementedBiddingServiceServer
+	agents []Agent
+}
+
+func (s *biddingServer) Bid(ctx context.Context, in *rtbpb.BidRequest) (*rtbpb.BidResponse, error) {
+	req, err := bidRequestFromPB(in)
+	if err != nil {
+		return nil, fmt.Errorf("grpc bid: %w", err)
+	}
+
+	auctionCtx, cancel := auctionDeadline(req)
+	defer cancel()
+
+	res, err := Auction(auctionCtx, req, s.agents)
+	if err != nil {
+		return nil, err
+	}
+
+	return bidResponseToPB(res), nil
+}
+
+// bidRequestFromPB converts a typed rtbpb.BidRequest into the
+// openrtb.BidRequest Auction() expects. imp.ExtJson is the one field this
+// tree still round-trips as opaque JSON (see proto/bidding.proto).
+func bidRequestFromPB(in *rtbpb.BidRequest) (*openrtb.BidRequest, error) {
+	req := &openrtb.BidRequest{
+		ID:   in.Id,
+		TMax: in.Tmax,
+		Imp:  make([]openrtb.Impression, len(in.Imp)),
+	}
+	for i, imp := range in.Imp {
+		req.Imp[i] = openrtb.Impression{
+			ID:               imp.Id,
+			BidFloor:         imp.Bidfloor,
+			BidFloorCurrency: imp.Bidfloorcur,
+		}
+		if len(imp.ExtJson) > 0 {
+			if err := json.Unmarshal(imp.ExtJson, &req.Imp[i].Ext); err != nil {
+				return nil, fmt.Errorf("invalid imp ext for imp %q: %w", imp.Id, err)
+			}
+		}
+	}
+	return req, nil
+}
+
+// bidResponseToPB converts the openrtb.BidResponse Auction() returns into
+// the typed rtbpb.BidResponse, setting NoBid instead of shipping an empty
+// seatbid list when no agent produced a bid. Auction already prunes
+// zero-bid seats (see pruneEmptySeats in auctions.go); the check below is
+// just defensive in case that ever changes.
+func bidResponseToPB(res *openrtb.BidResponse) *rtbpb.BidResponse {
+	out := &rtbpb.BidResponse{Id: res.ID}
+	for _, seat := range res.SeatBid {
+		if len(seat.Bid) == 0 {
+			continue
+		}
+		pbSeat := &rtbpb.SeatBid{Seat: seat.Seat, Bid: make([]*rtbpb.Bid, len(seat.Bid))}
+		for i, bid := range seat.Bid {
+			pbSeat.Bid[i] = &rtbpb.Bid{
+				Id:      bid.ID,
+				Impid:   bid.ImpID,
+				Crid:    bid.CreativeID,
+				Price:   bid.Price,
+				ExtJson: []byte(bid.Ext),
+			}
+		}
+		out.Seatbid = append(out.Seatbid, pbSeat)
+	}
+	if len(out.Seatbid) == 0 {
+		out.NoBid = true
+	}
+	return out
+}
+
+// setupGRPCHandlers starts the gRPC bidding listener alongside the fasthttp
+// one, sharing the same agents slice and Auction pipeline so the two
+// transports can't drift apart. It's a no-op when grpcPort is 0.
+func setupGRPCHandlers(agents []Agent) {
+	if grpcPort == 0 {
+		return
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		log.Fatalf("grpc: failed to listen on port %d: %s", grpcPort, err)
+	}
+
+	var opts []grpc.ServerOption
+	if grpcTLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(grpcTLSCertFile, grpcTLSKeyFile)
+		if err != nil {
+			log.Fatalf("grpc: failed to load TLS cert/key: %s", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	rtbpb.RegisterBiddingServiceServer(server, &biddingServer{agents: agents})
+
+	go func() {
+		log.Printf("Started gRPC bidding endpoint on port %d", grpcPort)
+		if err := server.Serve(lis); err != nil {
+			log.Fatalf("grpc: serve failed: %s", err)
+		}
+	}()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPClientConfig configures the connection pool and timeouts for a single
+// destination (e.g. the ACS or the banker). All durations are expressed in
+// the units RTBKIT operators are used to seeing in agents.json: milliseconds
+// for timeouts, seconds for the idle connection reaper.
+type HTTPClientConfig struct {
+	MaxIdleConnections           int  `json:"max_idle_connections"`
+	MaxIdleConnectionsPerHost    int  `json:"max_idle_connections_per_host"`
+	MaxConnsPerHost              int  `json:"max_conns_per_host"`
+	IdleConnectionTimeoutSeconds int  `json:"idle_connection_timeout_seconds"`
+	RequestTimeoutMs             int  `json:"request_timeout_ms"`
+	DialTimeoutMs                int  `json:"dial_timeout_ms"`
+	ForceAttemptHTTP2            bool `json:"force_attempt_http2"`
+}
+
+// ClientsConfig groups the per-destination HTTP client configuration. It is
+// loaded from its own JSON file (see LoadClientsConfig) so it can be tuned
+// independently from agents.json.
+type ClientsConfig struct {
+	ACS    HTTPClientConfig `json:"acs"`
+	Banker HTTPClientConfig `json:"banker"`
+}
+
+// DefaultClientsConfig returns the idle pool size and idle timeout we used
+// to get for free from Go's zero-value http.Client, but with the 2 idle
+// conns per host default raised to something that won't churn TCP
+// connections against the banker under load. Unlike that default, it also
+// caps connections per host at 64, bounds each request to 2s and each dial
+// to 500ms.
+func DefaultClientsConfig() ClientsConfig {
+	defaults := HTTPClientConfig{
+		MaxIdleConnections:           100,
+		MaxIdleConnectionsPerHost:    32,
+		MaxConnsPerHost:              64,
+		IdleConnectionTimeoutSeconds: 90,
+		RequestTimeoutMs:             2000,
+		DialTimeoutMs:                500,
+		ForceAttemptHTTP2:            true,
+	}
+	return ClientsConfig{ACS: defaults, Banker: defaults}
+}
+
+// LoadClientsConfig parses a JSON file holding a ClientsConfig. An empty
+// filepath is not an error: callers fall back to DefaultClientsConfig.
+func LoadClientsConfig(filepath string) (ClientsConfig, error) {
+	cfg := DefaultClientsConfig()
+	if filepath == "" {
+		return cfg, nil
+	}
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return ClientsConfig{}, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return ClientsConfig{}, err
+	}
+	return cfg, nil
+}
+
+// poolStats holds the counters instrumentedTransport updates on every round
+// trip. They're read with atomic loads by PoolStats so the stat output can
+// poll them without locking.
+type poolStats struct {
+	inFlight        int64
+	idleHighWater   int64
+	roundTripErrors int64
+}
+
+// instrumentedTransport wraps an *http.Transport to track in-flight request,
+// idle connection, and round-trip error counts, so the pools tuned per
+// destination can be observed instead of tuned blind.
+type instrumentedTransport struct {
+	transport *http.Transport
+	stats     poolStats
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.stats.inFlight, 1)
+	defer atomic.AddInt64(&t.stats.inFlight, -1)
+
+	// idleHighWater only has hooks for a connection becoming idle
+	// (PutIdleConn) and a later request reusing it (GotConn.WasIdle).
+	// net/http gives us no callback for IdleConnTimeout reaping a
+	// connection or the peer closing it while idle, so this only ever
+	// drifts upward between reuses - see the PoolStats doc comment.
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.WasIdle {
+				atomic.AddInt64(&t.stats.idleHighWater, -1)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if err == nil {
+				atomic.AddInt64(&t.stats.idleHighWater, 1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		// Counts any RoundTrip failure (dial, TLS handshake, timeout,
+		// connection reset mid-request, ...), not just failed dials.
+		atomic.AddInt64(&t.stats.roundTripErrors, 1)
+	}
+	return res, err
+}
+
+// PoolStats is a point-in-time snapshot of a pooled client's configuration
+// and in-flight usage, suitable for logging or exporting as a stat.
+//
+// IdleHighWater is an approximation, not a live idle-connection count:
+// net/http has no hook for a connection being reaped by IdleConnTimeout or
+// closed by the peer while idle, only for one becoming idle and later being
+// reused, so this can only drift upward between reuses. Treat it as "at
+// least this many connections have piled up idle since the last reuse",
+// useful for spotting a pool that's oversized relative to traffic, not as
+// an exact gauge to alert on.
+type PoolStats struct {
+	InFlight            int64
+	IdleHighWater       int64
+	RoundTripErrors     int64
+	MaxIdleConnections  int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+}
+
+func (t *instrumentedTransport) Stats() PoolStats {
+	return PoolStats{
+		InFlight:            atomic.LoadInt64(&t.stats.inFlight),
+		IdleHighWater:       atomic.LoadInt64(&t.stats.idleHighWater),
+		RoundTripErrors:     atomic.LoadInt64(&t.stats.roundTripErrors),
+		MaxIdleConnections:  t.transport.MaxIdleConns,
+		MaxIdleConnsPerHost: t.transport.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     t.transport.MaxConnsPerHost,
+	}
+}
+
+// NewHTTPClient builds an *http.Client with its own tuned *http.Transport
+// instead of sharing Go's global default transport. Every destination (ACS,
+// banker/pacer) gets one of these so their connection pools don't starve
+// each other.
+func NewHTTPClient(cfg HTTPClientConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.DialTimeoutMs) * time.Millisecond}
+
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        cfg.MaxIdleConnections,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnectionsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.IdleConnectionTimeoutSeconds) * time.Second,
+		ForceAttemptHTTP2:   cfg.ForceAttemptHTTP2,
+	}
+
+	return &http.Client{
+		Transport: &instrumentedTransport{transport: transport},
+		Timeout:   time.Duration(cfg.RequestTimeoutMs) * time.Millisecond,
+	}
+}
+
+// clientPoolStats reads back the PoolStats of a client built by
+// NewHTTPClient. It returns false for any other *http.Client, e.g. one built
+// by tests with a plain RoundTripper.
+func clientPoolStats(client *http.Client) (PoolStats, bool) {
+	t, ok := client.Transport.(*instrumentedTransport)
+	if !ok {
+		return PoolStats{}, false
+	}
+	return t.Stats(), true
+}
+
+// logPoolStats logs the pool stats for each named client once. Called from
+// startOperationalStatOutput's ticker (see main.go).
+func logPoolStats(clients map[string]*http.Client) {
+	for name, client := range clients {
+		stats, ok := clientPoolStats(client)
+		if !ok {
+			continue
+		}
+		log.Printf(
+			"pool stats [%s]: in-flight=%d idle-high-water=%d round-trip-errors=%d max-idle=%d max-idle-per-host=%d max-conns-per-host=%d",
+			name, stats.InFlight, stats.IdleHighWater, stats.RoundTripErrors,
+			stats.MaxIdleConnections, stats.MaxIdleConnsPerHost, stats.MaxConnsPerHost,
+		)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -8,6 +9,8 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -25,8 +28,23 @@ const (
 var bidderPort int
 var wg sync.WaitGroup
 
-// http client to pace agents (note that it's pointer)
-var client = &http.Client{}
+// drainTimeoutMs bounds how long cleanup waits, in total across all
+// listeners, for in-flight requests to finish draining before giving up on
+// a clean shutdown.
+var drainTimeoutMs = 5000
+
+// unregisterConcurrency bounds how many ACS unregister requests cleanup
+// fires off at once, so shutdown of a large agent fleet doesn't open one
+// connection per agent against a pool sized for steady-state traffic.
+const unregisterConcurrency = 8
+
+// acsClient and bankerClient are the pooled HTTP clients used to talk to
+// the ACS and to the banker/pacer respectively. Each gets its own tuned
+// *http.Transport (see httpclient.go) instead of sharing Go's global
+// default transport, whose 2-conns-per-host cap makes the pacer churn TCP
+// connections against the banker under load.
+var acsClient *http.Client
+var bankerClient *http.Client
 
 func printPortConfigs() {
 	log.Printf("Bidder port: %d", bidderPort)
@@ -34,7 +52,27 @@ func printPortConfigs() {
 	log.Printf("Event port: %d", BidderEvent)
 }
 
-func setupHandlers(agents []Agent) {
+// startServer starts a fasthttp server for handler on addr in its own
+// goroutine and returns it so cleanup can Shutdown it later. wg tracks the
+// goroutine so cleanup can block until every listener's serve loop has
+// returned; the serve loop returns once Shutdown closes the listener, and
+// waiting for in-flight requests to drain is done by Shutdown itself.
+func startServer(label string, addr string, handler fasthttp.RequestHandler) *fasthttp.Server {
+	server := &fasthttp.Server{Handler: handler}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := server.ListenAndServe(addr); err != nil {
+			log.Printf("%s listener on %s stopped: %s\n", label, addr, err)
+		}
+	}()
+	log.Printf("Started %s Mux", label)
+
+	return server
+}
+
+func setupHandlers(agents []Agent) *fasthttp.Server {
 	m := func(ctx *fasthttp.RequestCtx) {
 		switch string(ctx.Path()) {
 		case "/auctions":
@@ -44,36 +82,104 @@ func setupHandlers(agents []Agent) {
 		}
 	}
 
-	go fasthttp.ListenAndServe(fmt.Sprintf(":%d", bidderPort), m)
-	log.Println("Started Bid Mux")
+	return startServer("Bid", fmt.Sprintf(":%d", bidderPort), m)
 }
 
-func cleanup(agents []Agent) {
+// startOperationalStatOutput runs its own ticker, separate from
+// StartStatOutput, that on each tick logs the HTTP client pool stats (one
+// line per client) followed by the auction counters, so those two come out
+// together. They are not part of StartStatOutput's own output.
+func startOperationalStatOutput(clients map[string]*http.Client, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			logPoolStats(clients)
+			logAuctionStats()
+		}
+	}()
+}
+
+// cleanup drains and stops every listener, waits for their in-flight
+// handlers to finish, stops each agent's pacer, and unregisters agents from
+// the ACS in parallel. It returns 0 after a clean shutdown, 1 if draining
+// timed out.
+func cleanup(agents []Agent, servers []*fasthttp.Server) int {
+	log.Println("Shutting down...")
+
 	stopRedisSubscriber()
-	// Implement remove agent from ACS
-	for _, agent := range agents {
-		agent.UnregisterAgent(client, ACSIp, ACSPort)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(drainTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	drained := true
+	for _, server := range servers {
+		if err := server.ShutdownWithContext(shutdownCtx); err != nil {
+			log.Printf("listener failed to drain: %s\n", err)
+			drained = false
+		}
 	}
 
-	fmt.Println("Leaving...")
+	wg.Wait()
 
-	for {
-		wg.Done()
+	for i := range agents {
+		agents[i].StopPacer()
 	}
+
+	unregisterAgents(agents)
+
+	if !drained {
+		log.Println("Drain timed out.")
+		return 1
+	}
+	log.Println("Leaving...")
+	return 0
+}
+
+// unregisterAgents removes every agent's configuration from the ACS in
+// parallel, bounded to unregisterConcurrency at a time.
+func unregisterAgents(agents []Agent) {
+	sem := make(chan struct{}, unregisterConcurrency)
+	var pending sync.WaitGroup
+
+	for i := range agents {
+		pending.Add(1)
+		sem <- struct{}{}
+		go func(agent *Agent) {
+			defer pending.Done()
+			defer func() { <-sem }()
+			agent.UnregisterAgent(acsClient, ACSIp, ACSPort)
+		}(&agents[i])
+	}
+
+	pending.Wait()
 }
 
 func main() {
 	var agentsConfigFile = flag.String("config", "agents.json", "Configuration file in JSON.")
+	var clientsConfigFile = flag.String("client-config", "", "HTTP client pool configuration file in JSON. Defaults to built-in pool sizes.")
 	flag.IntVar(&bidderPort, "port", 7654, "Port to listen on for router")
+	flag.IntVar(&defaultTMaxMs, "default-tmax-ms", defaultTMaxMs, "Auction budget to use, in milliseconds, when BidRequest.TMax isn't set.")
+	flag.IntVar(&grpcPort, "grpc-port", 0, "Port to listen on for the gRPC bidding endpoint. 0 disables it.")
+	flag.StringVar(&grpcTLSCertFile, "grpc-tls-cert", "", "TLS certificate file for the gRPC listener. Leave empty to serve gRPC in plaintext.")
+	flag.StringVar(&grpcTLSKeyFile, "grpc-tls-key", "", "TLS private key file for the gRPC listener.")
+	flag.IntVar(&drainTimeoutMs, "drain-timeout-ms", drainTimeoutMs, "How long to wait, in milliseconds, for in-flight requests to drain on shutdown before giving up.")
 
 	flag.Parse()
 	if *agentsConfigFile == "" {
 		log.Fatal("You should provide a configuration file.")
 	}
 
+	clientsConfig, err := LoadClientsConfig(*clientsConfigFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	acsClient = NewHTTPClient(clientsConfig.ACS)
+	bankerClient = NewHTTPClient(clientsConfig.Banker)
+	startOperationalStatOutput(map[string]*http.Client{"acs": acsClient, "banker": bankerClient}, 30*time.Second)
+
 	setupClient()
 	go startRedisSubscriber()
-	wg.Add(1)
 
 	printPortConfigs()
 
@@ -83,33 +189,23 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, agent := range agents {
-		agent.RegisterAgent(client, ACSIp, ACSPort)
-		agent.StartPacer(client, BankerIp, BankerPort)
+	for i := range agents {
+		agents[i].RegisterAgent(acsClient, ACSIp, ACSPort)
+		agents[i].StartPacer(bankerClient, BankerIp, BankerPort)
 	}
 
 	StartStatOutput()
-	setupHandlers(agents)
-
-	go fasthttp.ListenAndServe(fmt.Sprintf(":%d", BidderEvent), eventMux)
-	log.Println("Started event Mux")
-
-	go fasthttp.ListenAndServe(fmt.Sprintf(":%d", BidderError), errorMux)
-	log.Println("Started error Mux")
-
-	go fasthttp.ListenAndServe(fmt.Sprintf(":%d", BidderWin), winMux)
-	log.Println("Started Win Mux")
-
-	wg.Add(3)
+	servers := []*fasthttp.Server{
+		setupHandlers(agents),
+		startServer("Event", fmt.Sprintf(":%d", BidderEvent), eventMux),
+		startServer("Error", fmt.Sprintf(":%d", BidderError), errorMux),
+		startServer("Win", fmt.Sprintf(":%d", BidderWin), winMux),
+	}
+	setupGRPCHandlers(agents)
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, os.Kill)
+	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM)
 
-	go func() {
-		<-c
-		cleanup(agents)
-		os.Exit(1)
-	}()
-
-	wg.Wait()
+	<-c
+	os.Exit(cleanup(agents, servers))
 }
@@ -0,0 +1,181 @@
+// Generated from proto/bidding.proto. This environment doesn't have protoc
+// and the protoc-gen-go plugin available to run the go:generate directive
+// in grpc.go, so these message types are hand-maintained instead of
+// machine-generated. Keep them in lockstep with proto/bidding.proto by hand
+// until `go generate ./...` can be run with the toolchain installed, at
+// which point it will overwrite this file with the real generated output.
+
+package rtbpb
+
+import "fmt"
+
+type BidRequest struct {
+	Id   string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Imp  []*Imp `protobuf:"bytes,2,rep,name=imp,proto3" json:"imp,omitempty"`
+	Tmax int64  `protobuf:"varint,3,opt,name=tmax,proto3" json:"tmax,omitempty"`
+}
+
+func (x *BidRequest) Reset()         { *x = BidRequest{} }
+func (x *BidRequest) String() string { return fmt.Sprintf("%+v", *x) }
+func (*BidRequest) ProtoMessage()    {}
+
+func (x *BidRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BidRequest) GetImp() []*Imp {
+	if x != nil {
+		return x.Imp
+	}
+	return nil
+}
+
+func (x *BidRequest) GetTmax() int64 {
+	if x != nil {
+		return x.Tmax
+	}
+	return 0
+}
+
+type Imp struct {
+	Id          string  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Bidfloor    float64 `protobuf:"fixed64,2,opt,name=bidfloor,proto3" json:"bidfloor,omitempty"`
+	Bidfloorcur string  `protobuf:"bytes,3,opt,name=bidfloorcur,proto3" json:"bidfloorcur,omitempty"`
+	ExtJson     []byte  `protobuf:"bytes,4,opt,name=ext_json,json=extJson,proto3" json:"ext_json,omitempty"`
+}
+
+func (x *Imp) Reset()         { *x = Imp{} }
+func (x *Imp) String() string { return fmt.Sprintf("%+v", *x) }
+func (*Imp) ProtoMessage()    {}
+
+func (x *Imp) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Imp) GetBidfloor() float64 {
+	if x != nil {
+		return x.Bidfloor
+	}
+	return 0
+}
+
+func (x *Imp) GetBidfloorcur() string {
+	if x != nil {
+		return x.Bidfloorcur
+	}
+	return ""
+}
+
+func (x *Imp) GetExtJson() []byte {
+	if x != nil {
+		return x.ExtJson
+	}
+	return nil
+}
+
+type BidResponse struct {
+	Id      string     `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Seatbid []*SeatBid `protobuf:"bytes,2,rep,name=seatbid,proto3" json:"seatbid,omitempty"`
+	NoBid   bool       `protobuf:"varint,3,opt,name=no_bid,json=noBid,proto3" json:"no_bid,omitempty"`
+}
+
+func (x *BidResponse) Reset()         { *x = BidResponse{} }
+func (x *BidResponse) String() string { return fmt.Sprintf("%+v", *x) }
+func (*BidResponse) ProtoMessage()    {}
+
+func (x *BidResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BidResponse) GetSeatbid() []*SeatBid {
+	if x != nil {
+		return x.Seatbid
+	}
+	return nil
+}
+
+func (x *BidResponse) GetNoBid() bool {
+	if x != nil {
+		return x.NoBid
+	}
+	return false
+}
+
+type SeatBid struct {
+	Seat string `protobuf:"bytes,1,opt,name=seat,proto3" json:"seat,omitempty"`
+	Bid  []*Bid `protobuf:"bytes,2,rep,name=bid,proto3" json:"bid,omitempty"`
+}
+
+func (x *SeatBid) Reset()         { *x = SeatBid{} }
+func (x *SeatBid) String() string { return fmt.Sprintf("%+v", *x) }
+func (*SeatBid) ProtoMessage()    {}
+
+func (x *SeatBid) GetSeat() string {
+	if x != nil {
+		return x.Seat
+	}
+	return ""
+}
+
+func (x *SeatBid) GetBid() []*Bid {
+	if x != nil {
+		return x.Bid
+	}
+	return nil
+}
+
+type Bid struct {
+	Id      string  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Impid   string  `protobuf:"bytes,2,opt,name=impid,proto3" json:"impid,omitempty"`
+	Crid    string  `protobuf:"bytes,3,opt,name=crid,proto3" json:"crid,omitempty"`
+	Price   float64 `protobuf:"fixed64,4,opt,name=price,proto3" json:"price,omitempty"`
+	ExtJson []byte  `protobuf:"bytes,5,opt,name=ext_json,json=extJson,proto3" json:"ext_json,omitempty"`
+}
+
+func (x *Bid) Reset()         { *x = Bid{} }
+func (x *Bid) String() string { return fmt.Sprintf("%+v", *x) }
+func (*Bid) ProtoMessage()    {}
+
+func (x *Bid) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Bid) GetImpid() string {
+	if x != nil {
+		return x.Impid
+	}
+	return ""
+}
+
+func (x *Bid) GetCrid() string {
+	if x != nil {
+		return x.Crid
+	}
+	return ""
+}
+
+func (x *Bid) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Bid) GetExtJson() []byte {
+	if x != nil {
+		return x.ExtJson
+	}
+	return nil
+}
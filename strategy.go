@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+
+	openrtb "gopkg.in/bsm/openrtb.v2"
+)
+
+// BidStrategy decides, per impression, whether an agent bids and for how
+// much. Agents declare their strategy in agents.json (see StrategyConfig)
+// and it's resolved once at load time through the registry below, so third
+// parties can add new strategies without editing this file.
+//
+// PriceBid returning a price <= 0 is treated by DoBid as "don't bid this
+// impression", the same way SelectCreative returning ok == false is.
+type BidStrategy interface {
+	SelectCreative(imp openrtb.Impression, eligible []Creative) (*Creative, bool)
+	PriceBid(req *openrtb.BidRequest, imp openrtb.Impression, creative Creative) float64
+
+	// SeatID reports which SeatBid a bid for creative should be grouped
+	// under. It's called once per bid, after SelectCreative has picked the
+	// winning creative, so a strategy can spread its bids across several
+	// seats (e.g. by creative format) instead of always using the
+	// exchange's default. The empty string means the default seat.
+	SeatID(creative Creative) string
+}
+
+// StrategyConfig is the `"strategy"` block of an agent in agents.json,
+// e.g. `{"type": "floor_aware", "params": {"price": 1.5}}`.
+type StrategyConfig struct {
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params"`
+}
+
+// StrategyFactory builds a BidStrategy for the agent it's attached to.
+// Implementations are free to ignore params they don't need, but should
+// reject unknown required fields by returning an error.
+type StrategyFactory func(agent *Agent, params json.RawMessage) (BidStrategy, error)
+
+var strategyRegistry = map[string]StrategyFactory{}
+
+// RegisterBidStrategy makes a strategy type available under agents.json's
+// `"strategy": {"type": "..."}`. It panics on duplicate registration, the
+// same way database/sql drivers do, since that only happens from a
+// conflicting init() and should fail loudly at startup.
+func RegisterBidStrategy(name string, factory StrategyFactory) {
+	if _, exists := strategyRegistry[name]; exists {
+		panic(fmt.Sprintf("bidstrategy: strategy %q already registered", name))
+	}
+	strategyRegistry[name] = factory
+}
+
+// resolveStrategy builds the BidStrategy for this agent's configuration,
+// defaulting to "fixed_price_random" (the historical fixed-price/random-
+// creative behavior) when agents.json doesn't declare one.
+func (agent *Agent) resolveStrategy() (BidStrategy, error) {
+	strategyType := agent.Strategy.Type
+	if strategyType == "" {
+		strategyType = "fixed_price_random"
+	}
+
+	factory, ok := strategyRegistry[strategyType]
+	if !ok {
+		return nil, fmt.Errorf("bidstrategy: unknown strategy type %q", strategyType)
+	}
+	return factory(agent, agent.Strategy.Params)
+}
+
+func init() {
+	RegisterBidStrategy("fixed_price_random", newFixedPriceRandomStrategy)
+	RegisterBidStrategy("floor_aware", newFloorAwareStrategy)
+	RegisterBidStrategy("pacing_aware", newPacingAwareStrategy)
+	RegisterBidStrategy("seat_by_format", newSeatByFormatStrategy)
+}
+
+// FixedPriceRandomStrategy is the original behavior: bid agent.Price on a
+// randomly picked eligible creative, regardless of floor or budget.
+type FixedPriceRandomStrategy struct {
+	Price float64
+}
+
+func newFixedPriceRandomStrategy(agent *Agent, params json.RawMessage) (BidStrategy, error) {
+	cfg := struct {
+		Price float64 `json:"price"`
+	}{Price: agent.Price}
+
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	return FixedPriceRandomStrategy{Price: cfg.Price}, nil
+}
+
+func (s FixedPriceRandomStrategy) SelectCreative(imp openrtb.Impression, eligible []Creative) (*Creative, bool) {
+	if len(eligible) == 0 {
+		return nil, false
+	}
+	n := rand.Intn(len(eligible))
+	return &eligible[n], true
+}
+
+func (s FixedPriceRandomStrategy) PriceBid(req *openrtb.BidRequest, imp openrtb.Impression, creative Creative) float64 {
+	return s.Price
+}
+
+func (s FixedPriceRandomStrategy) SeatID(creative Creative) string {
+	return ""
+}
+
+// FloorAwareStrategy bids a fixed price like FixedPriceRandomStrategy, but
+// no-bids instead of bidding under imp.BidFloor. The floor is only checked
+// when imp.BidFloorCurrency is empty or equals Currency; a floor in any
+// other currency is logged and the bid goes ahead unchecked.
+type FloorAwareStrategy struct {
+	Price    float64
+	Currency string
+}
+
+func newFloorAwareStrategy(agent *Agent, params json.RawMessage) (BidStrategy, error) {
+	cfg := struct {
+		Price    float64 `json:"price"`
+		Currency string  `json:"currency"`
+	}{Price: agent.Price, Currency: "USD"}
+
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	return FloorAwareStrategy{Price: cfg.Price, Currency: cfg.Currency}, nil
+}
+
+func (s FloorAwareStrategy) SelectCreative(imp openrtb.Impression, eligible []Creative) (*Creative, bool) {
+	if len(eligible) == 0 {
+		return nil, false
+	}
+	n := rand.Intn(len(eligible))
+	return &eligible[n], true
+}
+
+func (s FloorAwareStrategy) PriceBid(req *openrtb.BidRequest, imp openrtb.Impression, creative Creative) float64 {
+	if imp.BidFloor <= 0 {
+		return s.Price
+	}
+	if imp.BidFloorCurrency != "" && imp.BidFloorCurrency != s.Currency {
+		log.Printf("floor_aware: imp %s floor is in %s, not %s; bidding without floor check\n", imp.ID, imp.BidFloorCurrency, s.Currency)
+		return s.Price
+	}
+	if s.Price < imp.BidFloor {
+		return 0
+	}
+	return s.Price
+}
+
+func (s FloorAwareStrategy) SeatID(creative Creative) string {
+	return ""
+}
+
+// PacingAwareStrategy scales down how often the agent bids as its pacer-
+// reported balance depletes, instead of bidding at a constant rate and
+// blowing through budget in a burst.
+type PacingAwareStrategy struct {
+	agent           *Agent
+	price           float64
+	baseProbability float64
+}
+
+func newPacingAwareStrategy(agent *Agent, params json.RawMessage) (BidStrategy, error) {
+	cfg := struct {
+		Price float64 `json:"price"`
+	}{Price: agent.Price}
+
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	return &PacingAwareStrategy{agent: agent, price: cfg.Price, baseProbability: agent.Config.BidProbability}, nil
+}
+
+func (s *PacingAwareStrategy) SelectCreative(imp openrtb.Impression, eligible []Creative) (*Creative, bool) {
+	if len(eligible) == 0 {
+		return nil, false
+	}
+
+	probability := s.baseProbability
+	if s.agent.Balance > 0 {
+		probability *= float64(s.agent.RemainingBalance()) / float64(s.agent.Balance)
+	}
+	if probability <= 0 || rand.Float64() > probability {
+		return nil, false
+	}
+
+	n := rand.Intn(len(eligible))
+	return &eligible[n], true
+}
+
+func (s *PacingAwareStrategy) PriceBid(req *openrtb.BidRequest, imp openrtb.Impression, creative Creative) float64 {
+	return s.price
+}
+
+func (s *PacingAwareStrategy) SeatID(creative Creative) string {
+	return ""
+}
+
+// SeatByFormatStrategy bids a fixed price like FixedPriceRandomStrategy, but
+// groups its bids into a SeatBid named after the winning creative's format
+// (e.g. "banner", "video") instead of always using the exchange's default
+// seat, demonstrating that a single strategy can spread bids across more
+// than one seat.
+type SeatByFormatStrategy struct {
+	Price float64
+}
+
+func newSeatByFormatStrategy(agent *Agent, params json.RawMessage) (BidStrategy, error) {
+	cfg := struct {
+		Price float64 `json:"price"`
+	}{Price: agent.Price}
+
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	return SeatByFormatStrategy{Price: cfg.Price}, nil
+}
+
+func (s SeatByFormatStrategy) SelectCreative(imp openrtb.Impression, eligible []Creative) (*Creative, bool) {
+	if len(eligible) == 0 {
+		return nil, false
+	}
+	n := rand.Intn(len(eligible))
+	return &eligible[n], true
+}
+
+func (s SeatByFormatStrategy) PriceBid(req *openrtb.BidRequest, imp openrtb.Impression, creative Creative) float64 {
+	return s.Price
+}
+
+func (s SeatByFormatStrategy) SeatID(creative Creative) string {
+	return creative.Format
+}